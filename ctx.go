@@ -0,0 +1,228 @@
+package multifs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// OpenContextFS may optionally be implemented by a filesystem mounted
+// into a multifs.FS. When it is, OpenCtx forwards the context it was
+// given to OpenCtx instead of calling Open; this matters for
+// filesystems backed by something slow or remote (HTTP, S3, FUSE)
+// where cancellation and deadlines need to be honored, mirroring the
+// pattern used to retrofit webdav.FileSystem with a context.Context.
+type OpenContextFS interface {
+	fs.FS
+	OpenCtx(ctx context.Context, name string) (fs.File, error)
+}
+
+// ReadDirContextFS is the ReadDir equivalent of OpenContextFS.
+type ReadDirContextFS interface {
+	fs.FS
+	ReadDirCtx(ctx context.Context, name string) ([]fs.DirEntry, error)
+}
+
+// StatContextFS is the Stat equivalent of OpenContextFS.
+type StatContextFS interface {
+	fs.FS
+	StatCtx(ctx context.Context, name string) (fs.FileInfo, error)
+}
+
+func (b binding) openCtx(ctx context.Context, prefix, name string) (fs.File, error) {
+	translated := b.translate(prefix, name)
+	if cfs, ok := b.fs.(OpenContextFS); ok {
+		return cfs.OpenCtx(ctx, translated)
+	}
+	return b.fs.Open(translated)
+}
+
+func (b binding) readDirCtx(ctx context.Context, prefix, name string) ([]fs.DirEntry, error) {
+	translated := b.translate(prefix, name)
+	if cfs, ok := b.fs.(ReadDirContextFS); ok {
+		return cfs.ReadDirCtx(ctx, translated)
+	}
+	return fs.ReadDir(b.fs, translated)
+}
+
+func (b binding) statCtx(ctx context.Context, prefix, name string) (fs.FileInfo, error) {
+	translated := b.translate(prefix, name)
+	if cfs, ok := b.fs.(StatContextFS); ok {
+		return cfs.StatCtx(ctx, translated)
+	}
+	return fs.Stat(b.fs, translated)
+}
+
+// OpenCtx behaves exactly like Open, except that the given context is
+// forwarded to any mounted filesystem that implements OpenContextFS.
+// Filesystems that don't are still called via their plain Open method,
+// but ctx.Done() is checked before dispatching to them either way.
+func (mfs *FS) OpenCtx(ctx context.Context, name string) (fs.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+	name = path.Clean(name)
+
+	prefix, bindings, ok := mfs.resolve(name, false)
+	if !ok {
+		return nil, fmt.Errorf(`file %q was not found`, name)
+	}
+
+	var lastErr error
+	for _, b := range bindings {
+		f, err := b.openCtx(ctx, prefix, name)
+		if err == nil {
+			return f, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// mergeDirEntriesCtx does the merging for ReadDirCtx, forwarding ctx
+// to each binding: it merges the directory entries returned by each
+// binding at a mount point into a single list, de-duplicating by name.
+// When a name appears in more than one binding, the entry from the
+// earliest binding (the first one to report it) is kept.
+func mergeDirEntriesCtx(ctx context.Context, prefix, name string, bindings []binding) ([]fs.DirEntry, error) {
+	var merged []fs.DirEntry
+	seen := make(map[string]struct{})
+	var lastErr error
+	var anyOK bool
+	for _, b := range bindings {
+		entries, err := b.readDirCtx(ctx, prefix, name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		anyOK = true
+		for _, entry := range entries {
+			if _, ok := seen[entry.Name()]; ok {
+				continue
+			}
+			seen[entry.Name()] = struct{}{}
+			merged = append(merged, entry)
+		}
+	}
+	if !anyOK {
+		return nil, lastErr
+	}
+	return merged, nil
+}
+
+// ReadDirCtx behaves exactly like ReadDir, except that the given
+// context is forwarded to any mounted filesystem that implements
+// ReadDirContextFS.
+func (mfs *FS) ReadDirCtx(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	name = path.Clean(name)
+
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+
+	switch name {
+	case ".", "/":
+		list, _ := mfs.getPseudoDirEntries("")
+		if bindings := mfs.root.bindings; bindings != nil {
+			extra, err := mergeDirEntriesCtx(ctx, "/", ".", bindings)
+			if err != nil && len(list) == 0 {
+				return nil, err
+			}
+			seen := make(map[string]struct{}, len(list))
+			for _, entry := range list {
+				seen[entry.Name()] = struct{}{}
+			}
+			for _, entry := range extra {
+				if _, ok := seen[entry.Name()]; ok {
+					continue
+				}
+				seen[entry.Name()] = struct{}{}
+				list = append(list, entry)
+			}
+		}
+		return list, nil
+	}
+
+	// if the path is not absolute, assume "/" + name
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+
+	if prefix, bindings, ok := mfs.resolve(name, true); ok {
+		dirName := name
+		if prefix == name {
+			dirName = "."
+		}
+		return mergeDirEntriesCtx(ctx, prefix, dirName, bindings)
+	}
+
+	if list, matched := mfs.getPseudoDirEntries(name); matched {
+		return list, nil
+	}
+
+	return nil, fmt.Errorf(`no such directory %q`, name)
+}
+
+// statBindingsCtx walks bindings in order and returns the fs.FileInfo
+// from the first one that successfully stats the translated name (the
+// same "first binding wins" rule used by OpenCtx).
+func statBindingsCtx(ctx context.Context, prefix, name string, bindings []binding) (fs.FileInfo, error) {
+	var lastErr error
+	for _, b := range bindings {
+		fi, err := b.statCtx(ctx, prefix, name)
+		if err == nil {
+			return fi, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// StatCtx behaves exactly like Stat, except that the given context is
+// forwarded to any mounted filesystem that implements StatContextFS.
+func (mfs *FS) StatCtx(ctx context.Context, name string) (fs.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+
+	name = path.Clean(name)
+
+	// Current dir = "."
+	// Root dir    = "/"
+	switch name {
+	case ".", "/":
+		return dirFileInfo(name), nil
+	}
+
+	// if the path is not absolute, assume "/" + name
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+
+	if prefix, bindings, ok := mfs.resolve(name, true); ok {
+		statName := name
+		if prefix == name {
+			statName = "."
+		}
+		return statBindingsCtx(ctx, prefix, statName, bindings)
+	}
+
+	// a pseudo-directory: nothing is mounted here, but mount points are
+	// nested beneath it (the same directories getPseudoDirEntries lists)
+	if node := mfs.nodeAt(name); node != nil && len(node.children) != 0 {
+		return dirFileInfo(path.Base(name)), nil
+	}
+
+	return nil, fmt.Errorf(`file %s not found`, name)
+}
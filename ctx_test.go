@@ -0,0 +1,155 @@
+package multifs_test
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lestrrat-go/multifs"
+	"github.com/stretchr/testify/require"
+)
+
+type ctxFSKey string
+
+// ctxAwareFS wraps an fstest.MapFS and records the context it was
+// opened with, so tests can assert that multifs.FS forwards it.
+type ctxAwareFS struct {
+	fstest.MapFS
+	lastCtx context.Context
+}
+
+func (c *ctxAwareFS) OpenCtx(ctx context.Context, name string) (fs.File, error) {
+	c.lastCtx = ctx
+	return c.MapFS.Open(name)
+}
+
+func TestOpenCtx(t *testing.T) {
+	cfs := &ctxAwareFS{MapFS: fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("hi")},
+	}}
+
+	var mfs multifs.FS
+	require.NoError(t, mfs.Mount("/x", cfs), `mfs.Mount(/x) should succeed`)
+
+	t.Run("context is forwarded to OpenContextFS", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ctxFSKey("id"), "req-1")
+		f, err := mfs.OpenCtx(ctx, "/x/a.txt")
+		require.NoError(t, err, `mfs.OpenCtx should succeed`)
+		defer f.Close()
+
+		require.Equal(t, "req-1", cfs.lastCtx.Value(ctxFSKey("id")), `context should have been forwarded`)
+	})
+
+	t.Run("cancelled context short-circuits dispatch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := mfs.OpenCtx(ctx, "/x/a.txt")
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("mounted FS without OpenContextFS still works", func(t *testing.T) {
+		plain := fstest.MapFS{"b.txt": &fstest.MapFile{Data: []byte("bye")}}
+		var mfs multifs.FS
+		require.NoError(t, mfs.Mount("/y", plain), `mfs.Mount(/y) should succeed`)
+
+		f, err := mfs.OpenCtx(context.Background(), "/y/b.txt")
+		require.NoError(t, err, `mfs.OpenCtx should fall back to Open`)
+		require.NoError(t, f.Close())
+	})
+}
+
+// readDirCtxAwareFS wraps an fstest.MapFS and records the context it
+// was read with, so tests can assert that multifs.FS forwards it.
+type readDirCtxAwareFS struct {
+	fstest.MapFS
+	lastCtx context.Context
+}
+
+func (c *readDirCtxAwareFS) ReadDirCtx(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	c.lastCtx = ctx
+	return fs.ReadDir(c.MapFS, name)
+}
+
+func TestReadDirCtx(t *testing.T) {
+	cfs := &readDirCtxAwareFS{MapFS: fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("hi")},
+	}}
+
+	var mfs multifs.FS
+	require.NoError(t, mfs.Mount("/x", cfs), `mfs.Mount(/x) should succeed`)
+
+	t.Run("context is forwarded to ReadDirContextFS", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ctxFSKey("id"), "req-2")
+		_, err := mfs.ReadDirCtx(ctx, "/x")
+		require.NoError(t, err, `mfs.ReadDirCtx should succeed`)
+
+		require.Equal(t, "req-2", cfs.lastCtx.Value(ctxFSKey("id")), `context should have been forwarded`)
+	})
+
+	t.Run("cancelled context short-circuits dispatch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := mfs.ReadDirCtx(ctx, "/x")
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("mounted FS without ReadDirContextFS still works", func(t *testing.T) {
+		plain := fstest.MapFS{"b.txt": &fstest.MapFile{Data: []byte("bye")}}
+		var mfs multifs.FS
+		require.NoError(t, mfs.Mount("/y", plain), `mfs.Mount(/y) should succeed`)
+
+		entries, err := mfs.ReadDirCtx(context.Background(), "/y")
+		require.NoError(t, err, `mfs.ReadDirCtx should fall back to fs.ReadDir`)
+		require.Len(t, entries, 1)
+	})
+}
+
+// statCtxAwareFS wraps an fstest.MapFS and records the context it was
+// stat'd with, so tests can assert that multifs.FS forwards it.
+type statCtxAwareFS struct {
+	fstest.MapFS
+	lastCtx context.Context
+}
+
+func (c *statCtxAwareFS) StatCtx(ctx context.Context, name string) (fs.FileInfo, error) {
+	c.lastCtx = ctx
+	return fs.Stat(c.MapFS, name)
+}
+
+func TestStatCtx(t *testing.T) {
+	cfs := &statCtxAwareFS{MapFS: fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("hi")},
+	}}
+
+	var mfs multifs.FS
+	require.NoError(t, mfs.Mount("/x", cfs), `mfs.Mount(/x) should succeed`)
+
+	t.Run("context is forwarded to StatContextFS", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ctxFSKey("id"), "req-3")
+		_, err := mfs.StatCtx(ctx, "/x/a.txt")
+		require.NoError(t, err, `mfs.StatCtx should succeed`)
+
+		require.Equal(t, "req-3", cfs.lastCtx.Value(ctxFSKey("id")), `context should have been forwarded`)
+	})
+
+	t.Run("cancelled context short-circuits dispatch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := mfs.StatCtx(ctx, "/x/a.txt")
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("mounted FS without StatContextFS still works", func(t *testing.T) {
+		plain := fstest.MapFS{"b.txt": &fstest.MapFile{Data: []byte("bye")}}
+		var mfs multifs.FS
+		require.NoError(t, mfs.Mount("/y", plain), `mfs.Mount(/y) should succeed`)
+
+		fi, err := mfs.StatCtx(context.Background(), "/y/b.txt")
+		require.NoError(t, err, `mfs.StatCtx should fall back to fs.Stat`)
+		require.Equal(t, "b.txt", fi.Name())
+	})
+}
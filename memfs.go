@@ -0,0 +1,433 @@
+package multifs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemFS is an in-memory WritableFS. It is primarily intended to be used
+// as the upper, writable layer of a NewOverlayFS, e.g. to let callers
+// mount a scratch filesystem over a read-only embed.FS without touching
+// disk, but it is a complete WritableFS on its own and can be mounted
+// directly.
+type MemFS struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	dir     bool
+	mode    fs.FileMode
+	modTime time.Time
+	data    []byte
+}
+
+// NewMemFS creates an empty in-memory filesystem, with just a root
+// directory.
+func NewMemFS() *MemFS {
+	return &MemFS{
+		nodes: map[string]*memNode{
+			".": {dir: true, mode: fs.ModeDir | 0o755},
+		},
+	}
+}
+
+var (
+	_ fs.FS      = (*MemFS)(nil)
+	_ WritableFS = (*MemFS)(nil)
+)
+
+func (m *MemFS) info(name string, n *memNode) fs.FileInfo {
+	return &memFileInfo{name: path.Base(name), node: n}
+}
+
+func (m *MemFS) lookup(name string) (*memNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrInvalid
+	}
+	n, ok := m.nodes[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return n, nil
+}
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n, err := m.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if n.dir {
+		entries, _ := m.readDirNoLock(name)
+		return &memDirFile{info: m.info(name, n), entries: entries}, nil
+	}
+
+	data := make([]byte, len(n.data))
+	copy(data, n.data)
+	return &memReadFile{info: m.info(name, n), Reader: bytes.NewReader(data)}, nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n, err := m.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return m.info(name, n), nil
+}
+
+func (m *MemFS) readDirNoLock(name string) ([]fs.DirEntry, error) {
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+
+	var entries []fs.DirEntry
+	for p, n := range m.nodes {
+		if p == name || !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		rel := strings.TrimPrefix(p, prefix)
+		if strings.Contains(rel, "/") {
+			continue
+		}
+		entries = append(entries, &memDirEntry{name: rel, node: n})
+	}
+	return entries, nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n, err := m.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if !n.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return m.readDirNoLock(name)
+}
+
+func (m *MemFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	n, ok := m.nodes[name]
+	if !ok {
+		if flag&os.O_CREATE == 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		if dir := path.Dir(name); dir != "." {
+			if parent, ok := m.nodes[dir]; !ok || !parent.dir {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+			}
+		}
+		n = &memNode{mode: perm, modTime: time.Time{}}
+		m.nodes[name] = n
+	} else if n.dir {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if flag&os.O_TRUNC != 0 {
+		n.data = nil
+	}
+
+	var offset int64
+	if flag&os.O_APPEND != 0 {
+		offset = int64(len(n.data))
+	}
+
+	return &memWriteFile{fs: m, name: name, node: n, offset: offset}, nil
+}
+
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, ok := m.nodes[name]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if dir := path.Dir(name); dir != "." {
+		if parent, ok := m.nodes[dir]; !ok || !parent.dir {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	m.nodes[name] = &memNode{dir: true, mode: fs.ModeDir | perm}
+	return nil
+}
+
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	var built string
+	for _, part := range strings.Split(name, "/") {
+		if built == "" {
+			built = part
+		} else {
+			built = built + "/" + part
+		}
+		if n, ok := m.nodes[built]; ok {
+			if !n.dir {
+				return &fs.PathError{Op: "mkdir", Path: built, Err: fs.ErrExist}
+			}
+			continue
+		}
+		m.nodes[built] = &memNode{dir: true, mode: fs.ModeDir | perm}
+	}
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.nodes[name]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if n.dir {
+		if entries, _ := m.readDirNoLock(name); len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+		}
+	}
+	delete(m.nodes, name)
+	return nil
+}
+
+func (m *MemFS) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefix := name + "/"
+	for p := range m.nodes {
+		if p == name || strings.HasPrefix(p, prefix) {
+			delete(m.nodes, p)
+		}
+	}
+	return nil
+}
+
+func (m *MemFS) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.nodes[oldname]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+
+	if oldname == newname {
+		return nil
+	}
+
+	if dir := path.Dir(newname); dir != "." {
+		if parent, ok := m.nodes[dir]; !ok || !parent.dir {
+			return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrNotExist}
+		}
+	}
+
+	if dst, ok := m.nodes[newname]; ok && dst.dir {
+		if entries, _ := m.readDirNoLock(newname); len(entries) > 0 {
+			return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrInvalid}
+		}
+	}
+
+	// newname is absent, a regular file, or an empty directory at this
+	// point; clear it (and any leftover descendants) anyway so re-parenting
+	// oldname's own children below can't leave them mixed in with
+	// whatever used to live at newname.
+	destPrefix := newname + "/"
+	for p := range m.nodes {
+		if p == newname || strings.HasPrefix(p, destPrefix) {
+			delete(m.nodes, p)
+		}
+	}
+
+	prefix := oldname + "/"
+	for p, child := range m.nodes {
+		if p == oldname {
+			continue
+		}
+		if strings.HasPrefix(p, prefix) {
+			m.nodes[newname+"/"+strings.TrimPrefix(p, prefix)] = child
+			delete(m.nodes, p)
+		}
+	}
+	m.nodes[newname] = n
+	delete(m.nodes, oldname)
+	return nil
+}
+
+func (m *MemFS) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.nodes[name]
+	if !ok {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrNotExist}
+	}
+	n.modTime = mtime
+	return nil
+}
+
+type memFileInfo struct {
+	name string
+	node *memNode
+}
+
+func (fi *memFileInfo) Name() string       { return fi.name }
+func (fi *memFileInfo) Size() int64        { return int64(len(fi.node.data)) }
+func (fi *memFileInfo) Mode() fs.FileMode  { return fi.node.mode }
+func (fi *memFileInfo) ModTime() time.Time { return fi.node.modTime }
+func (fi *memFileInfo) IsDir() bool        { return fi.node.dir }
+func (fi *memFileInfo) Sys() interface{}   { return nil }
+
+type memDirEntry struct {
+	name string
+	node *memNode
+}
+
+func (d *memDirEntry) Name() string      { return d.name }
+func (d *memDirEntry) IsDir() bool       { return d.node.dir }
+func (d *memDirEntry) Type() fs.FileMode { return d.node.mode.Type() }
+func (d *memDirEntry) Info() (fs.FileInfo, error) {
+	return &memFileInfo{name: d.name, node: d.node}, nil
+}
+
+// memReadFile is returned by Open for a regular file: a read-only
+// snapshot of the file's contents at the time it was opened.
+type memReadFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *memReadFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memReadFile) Close() error               { return nil }
+
+// memDirFile is returned by Open for a directory.
+type memDirFile struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (f *memDirFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memDirFile) Close() error               { return nil }
+func (f *memDirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.info.Name(), Err: fs.ErrInvalid}
+}
+
+func (f *memDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := f.entries[f.offset:]
+		f.offset = len(f.entries)
+		return entries, nil
+	}
+
+	if f.offset >= len(f.entries) {
+		return nil, io.EOF
+	}
+	end := f.offset + n
+	if end > len(f.entries) {
+		end = len(f.entries)
+	}
+	entries := f.entries[f.offset:end]
+	f.offset = end
+	return entries, nil
+}
+
+// memWriteFile is returned by OpenFile; writes go straight through to
+// the backing node under the filesystem's lock, so Close is a no-op.
+type memWriteFile struct {
+	fs     *MemFS
+	name   string
+	node   *memNode
+	offset int64
+}
+
+func (f *memWriteFile) Stat() (fs.FileInfo, error) {
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	return f.fs.info(f.name, f.node), nil
+}
+
+func (f *memWriteFile) Read(p []byte) (int, error) {
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+
+	if f.offset >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memWriteFile) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memWriteFile) WriteAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	end := off + int64(len(p))
+	if end > int64(len(f.node.data)) {
+		grown := make([]byte, end)
+		copy(grown, f.node.data)
+		f.node.data = grown
+	}
+	copy(f.node.data[off:end], p)
+	f.node.modTime = time.Time{}
+	return len(p), nil
+}
+
+func (f *memWriteFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.RLock()
+	size := int64(len(f.node.data))
+	f.fs.mu.RUnlock()
+
+	switch whence {
+	case io.SeekStart:
+		f.offset = offset
+	case io.SeekCurrent:
+		f.offset += offset
+	case io.SeekEnd:
+		f.offset = size + offset
+	default:
+		return 0, fs.ErrInvalid
+	}
+	return f.offset, nil
+}
+
+func (f *memWriteFile) Close() error {
+	return nil
+}
@@ -6,34 +6,72 @@
 package multifs
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"path"
-	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
+// BindMode controls how a binding added via Bind() interacts with any
+// other filesystems already bound at the same prefix.
+type BindMode int
+
+const (
+	// BindReplace removes any existing bindings at the prefix and
+	// replaces them with the new one. Mount records its binding with
+	// this mode, but refuses a prefix that already has bindings rather
+	// than replacing them.
+	BindReplace BindMode = iota
+	// BindBefore inserts the new filesystem ahead of the bindings that
+	// are already present at the prefix, so it is consulted first.
+	BindBefore
+	// BindAfter appends the new filesystem after the bindings that are
+	// already present at the prefix, so it is consulted last.
+	BindAfter
+)
+
+// binding associates a single fs.FS with the subdirectory of that FS
+// that should be exposed, and the mode that was used to add it. A
+// mount point may have more than one binding, in which case they are
+// unioned together: Open/Stat try each binding in order and return the
+// first hit, and ReadDir merges the entries of all of them.
+type binding struct {
+	fs     fs.FS
+	subdir string
+	mode   BindMode
+}
+
 type FS struct {
 	mu sync.RWMutex
 
-	// mountPoints holds a sorted list of names so that we can
-	// match paths from longest to shortest
-	mountPoints []string
-	fsmap       map[string]fs.FS
+	// root is the entry point of a path-segment trie keyed on the
+	// "/"-separated components of each mount prefix, so that Mount,
+	// Unmount, and prefix lookup are O(depth) rather than O(mounts).
+	root *trieNode
 }
 
 // New creates an empty multifs.FS object. You will need to call Mount()
 // to add other filesystems
 func New() *FS {
-	return &FS{}
+	return &FS{root: newTrieNode()}
 }
 
 func (mfs *FS) initNoLock() {
-	if mfs.fsmap == nil {
-		mfs.fsmap = make(map[string]fs.FS)
+	if mfs.root == nil {
+		mfs.root = newTrieNode()
+	}
+}
+
+func normalizePrefix(prefix string) (string, error) {
+	// The prefix must be normalized.
+	prefix = path.Clean(prefix)
+	if !strings.HasPrefix(prefix, "/") {
+		return "", fmt.Errorf(`invalid prefix (path was normalized to %q)`, prefix)
 	}
+	return prefix, nil
 }
 
 // Mount associates prefix with another fs.FS. For example
@@ -43,192 +81,170 @@ func (mfs *FS) initNoLock() {
 //
 // Mount currently only understands linux-style paths (technically
 // it uses "path" package).
+//
+// Mount does not allow a prefix to be mounted more than once; to layer
+// multiple filesystems at the same prefix, use Bind instead.
 func (mfs *FS) Mount(prefix string, other fs.FS) error {
-	// The prefix must be normalized.
-	prefix = path.Clean(prefix)
-	if !strings.HasPrefix(prefix, "/") {
-		return fmt.Errorf(`invalid prefix (path was normalized to %q)`, prefix)
+	return mfs.MountSub(prefix, other, ".")
+}
+
+// MountSub is like Mount, except that it exposes subdir of other at
+// prefix instead of other's root. For example,
+// MountSub("/static", embedFS, "internal/assets") makes
+// "internal/assets/foo.js" within embedFS available as
+// "/static/foo.js".
+//
+// MountSub does not call fs.Sub on other: the subdir is instead
+// prepended by translate() on every Open/ReadDir/Stat, so mounting
+// doesn't pay fs.Sub's allocation cost up front, and repeated access
+// doesn't pay it per call either.
+//
+// MountSub does not allow a prefix to be mounted more than once; to
+// layer multiple filesystems at the same prefix, use Bind instead.
+func (mfs *FS) MountSub(prefix string, other fs.FS, subdir string) error {
+	prefix, err := normalizePrefix(prefix)
+	if err != nil {
+		return err
 	}
+	subdir = path.Clean(subdir)
 
 	mfs.mu.Lock()
 	defer mfs.mu.Unlock()
 
 	mfs.initNoLock()
 
-	if _, ok := mfs.fsmap[prefix]; ok {
+	node := mfs.nodeForWrite(prefix)
+	if node.bindings != nil {
 		return fmt.Errorf(`prefix %q has already been mounted`, prefix)
 	}
 
-	mountPoints := append(mfs.mountPoints, prefix)
-
-	// TODO: Yeah... obviously we can optimize this so that we don't
-	// have to sort it every time. Patches welcome
-	sort.Slice(mountPoints, func(i, j int) bool {
-		// longest matches come first
-		return len(mountPoints[i]) > len(mountPoints[j])
-	})
-
-	mfs.mountPoints = mountPoints
-	mfs.fsmap[prefix] = other
+	node.bindings = []binding{{fs: other, subdir: subdir}}
 	return nil
 }
 
-func (mfs *FS) Open(name string) (fs.File, error) {
-	mfs.mu.RLock()
-	defer mfs.mu.RUnlock()
-	name = path.Clean(name)
-
-	for _, prefix := range mfs.mountPoints {
-		if !strings.HasPrefix(name, prefix+"/") {
-			continue
-		}
-
-		src := mfs.fsmap[prefix]
-		return src.Open(strings.TrimPrefix(name, prefix+"/"))
-	}
-	return nil, fmt.Errorf(`file %q was not found`, name)
-}
-
-func (mfs *FS) Unmount(prefix string) error {
-	// The prefix must be normalized.
-	prefix = path.Clean(prefix)
-	if !strings.HasPrefix(prefix, "/") {
-		return fmt.Errorf(`invalid prefix (path was normalized to %q)`, prefix)
+// Bind associates prefix with another fs.FS, rooted at srcSubdir within
+// that filesystem, similar to Mount. Unlike Mount, Bind allows more than
+// one filesystem to be bound at the same prefix: mode controls how the
+// new binding is combined with any bindings that are already present.
+//
+// With BindBefore or BindAfter, the filesystems at prefix are unioned
+// together: Open and Stat try each binding in order (a BindBefore
+// binding ahead of those already present, a BindAfter binding behind
+// them) and return the first hit, and ReadDir merges the directory
+// entries of all of them (when a name appears in more than one binding,
+// the entry from the first one in that order wins). This is similar to godoc's
+// vfs.NameSpace.Bind.
+func (mfs *FS) Bind(prefix string, other fs.FS, srcSubdir string, mode BindMode) error {
+	prefix, err := normalizePrefix(prefix)
+	if err != nil {
+		return err
 	}
+	srcSubdir = path.Clean(srcSubdir)
 
 	mfs.mu.Lock()
 	defer mfs.mu.Unlock()
 
 	mfs.initNoLock()
 
-	if _, ok := mfs.fsmap[prefix]; !ok {
-		return fmt.Errorf(`prefix %q has not been mounted`, prefix)
-	}
+	b := binding{fs: other, subdir: srcSubdir, mode: mode}
 
-	for i, n := range mfs.mountPoints {
-		if n != prefix {
-			continue
-		}
-
-		// TODO: inefficient
-		mfs.mountPoints = append(mfs.mountPoints[:i], mfs.mountPoints[i+1:]...)
-
-		delete(mfs.fsmap, prefix)
-		break
+	node := mfs.nodeForWrite(prefix)
+	switch mode {
+	case BindBefore:
+		node.bindings = append([]binding{b}, node.bindings...)
+	case BindAfter:
+		node.bindings = append(node.bindings, b)
+	default: // BindReplace
+		node.bindings = []binding{b}
 	}
 	return nil
 }
 
-func (mfs *FS) getPseudoDirEntries(base string) ([]fs.DirEntry, bool) {
-	var matched int
-	uniq := make(map[string]struct{})
-	for _, prefix := range mfs.mountPoints {
-		if base != "" {
-			if !strings.HasPrefix(prefix, base) {
-				continue
-			}
-		}
-
-		// counter to keep track if we actually had a prefix match
-		matched++
-
-		// e.g. prefix=/a/b/c is under base=/a
-
-		p := strings.TrimPrefix(prefix, base+"/")
-		// p = "b/c"
-
-		// in go 1.19, this would have been
-		// s, _, _ := strings.Cut(p, "/")
-		// uniq[s] = struct{}{}
-
-		var s string
-		if i := strings.Index(p, "/"); i >= 0 {
-			s = p[:i]
-		} else {
-			s = p
-		}
-		uniq[s] = struct{}{}
-	}
-
-	var list []fs.DirEntry
-	for k := range uniq {
-		list = append(list, dirEntry(k))
-	}
-	return list, matched > 0
+// translate computes the name that should be passed to a binding's
+// fs.FS, given the name requested of the mount point at prefix.
+func (b binding) translate(prefix, name string) string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(name, prefix), "/")
+	return path.Join(b.subdir, rel)
 }
 
-func (mfs *FS) ReadDir(name string) ([]fs.DirEntry, error) {
-	name = path.Clean(name)
-
-	mfs.mu.RLock()
-	defer mfs.mu.RUnlock()
+// Open implements fs.FS. See OpenCtx if you need the request to carry a
+// context.Context down to mounted filesystems that understand one.
+func (mfs *FS) Open(name string) (fs.File, error) {
+	return mfs.OpenCtx(context.Background(), name)
+}
 
-	switch name {
-	case ".", "/":
-		list, _ := mfs.getPseudoDirEntries("")
-		return list, nil
+// Unmount removes all bindings (however they were added, via Mount or
+// Bind) at prefix, and prunes any now-empty trie segments left behind.
+func (mfs *FS) Unmount(prefix string) error {
+	prefix, err := normalizePrefix(prefix)
+	if err != nil {
+		return err
 	}
 
-	// if the path is not absolute, assume "/" + name
-	if !strings.HasPrefix(name, "/") {
-		name = "/" + name
-	}
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
 
-	// emulation required for these
-	if src, ok := mfs.fsmap[name]; ok {
-		return fs.ReadDir(src, ".")
-	}
+	mfs.initNoLock()
 
-	for _, prefix := range mfs.mountPoints {
-		if !strings.HasPrefix(name, prefix+"/") {
-			continue
+	segments := pathSegments(prefix)
+	chain := make([]*trieNode, len(segments)+1)
+	chain[0] = mfs.root
+	cur := mfs.root
+	for i, seg := range segments {
+		child, ok := cur.children[seg]
+		if !ok {
+			return fmt.Errorf(`prefix %q has not been mounted`, prefix)
 		}
-
-		src := mfs.fsmap[prefix]
-		return fs.ReadDir(src, strings.TrimPrefix(name, prefix+"/"))
+		chain[i+1] = child
+		cur = child
 	}
 
-	if list, matched := mfs.getPseudoDirEntries(name); matched {
-		return list, nil
+	if cur.bindings == nil {
+		return fmt.Errorf(`prefix %q has not been mounted`, prefix)
 	}
-
-	return nil, fmt.Errorf(`no such directory %q`, name)
-}
-
-func (mfs *FS) Stat(name string) (fs.FileInfo, error) {
-	mfs.mu.RLock()
-	defer mfs.mu.RUnlock()
-
-	name = path.Clean(name)
-
-	// Current dir = "."
-	// Root dir    = "/"
-	switch name {
-	case ".", "/":
-		return dirFileInfo(name), nil
+	cur.bindings = nil
+
+	// prune any trailing nodes that are now dead weight: no bindings of
+	// their own and no children left.
+	for i := len(segments); i > 0; i-- {
+		node := chain[i]
+		if node.bindings != nil || len(node.children) != 0 {
+			break
+		}
+		delete(chain[i-1].children, segments[i-1])
 	}
+	return nil
+}
 
-	// if the path is not absolute, assume "/" + name
-	if !strings.HasPrefix(name, "/") {
-		name = "/" + name
+// getPseudoDirEntries synthesizes the directory entries for base that
+// come from mount points nested somewhere underneath it, even though
+// nothing is mounted at base itself (e.g. base="/a" when only "/a/b/c"
+// is mounted).
+func (mfs *FS) getPseudoDirEntries(base string) ([]fs.DirEntry, bool) {
+	node := mfs.nodeAt(base)
+	if node == nil || len(node.children) == 0 {
+		return nil, false
 	}
 
-	// emulation required for these
-	if src, ok := mfs.fsmap[name]; ok {
-		return fs.Stat(src, ".")
+	list := make([]fs.DirEntry, 0, len(node.children))
+	for name := range node.children {
+		list = append(list, dirEntry(name))
 	}
+	return list, true
+}
 
-	for _, prefix := range mfs.mountPoints {
-		if !strings.HasPrefix(name, prefix+"/") {
-			continue
-		}
-
-		src := mfs.fsmap[prefix]
-		return fs.Stat(src, strings.TrimPrefix(name, prefix+"/"))
-	}
+// ReadDir implements fs.ReadDirFS. See ReadDirCtx if you need the
+// request to carry a context.Context down to mounted filesystems that
+// understand one.
+func (mfs *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return mfs.ReadDirCtx(context.Background(), name)
+}
 
-	// TODO: partial matches?
-	return nil, fmt.Errorf(`file %s not found`, name)
+// Stat implements fs.StatFS. See StatCtx if you need the request to
+// carry a context.Context down to mounted filesystems that understand
+// one.
+func (mfs *FS) Stat(name string) (fs.FileInfo, error) {
+	return mfs.StatCtx(context.Background(), name)
 }
 
 type dirFileInfo string
@@ -0,0 +1,80 @@
+package multifs_test
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lestrrat-go/multifs"
+)
+
+// buildManyMounts returns a *multifs.FS with n distinct filesystems
+// mounted at n distinct prefixes, used to benchmark Mount/Unmount and
+// prefix lookup at a scale where a linear scan of every mount point
+// would show up in the profile.
+func buildManyMounts(b *testing.B, n int) (*multifs.FS, []string) {
+	b.Helper()
+
+	mfs := multifs.New()
+	prefixes := make([]string, n)
+	for i := 0; i < n; i++ {
+		prefix := fmt.Sprintf("/mnt/%d/%d", i%100, i)
+		sub := fstest.MapFS{
+			"file.txt": &fstest.MapFile{Data: []byte("hello")},
+		}
+		if err := mfs.Mount(prefix, sub); err != nil {
+			b.Fatalf(`mfs.Mount(%q) should succeed: %s`, prefix, err)
+		}
+		prefixes[i] = prefix
+	}
+	return mfs, prefixes
+}
+
+func BenchmarkMount(b *testing.B) {
+	for _, n := range []int{10, 100, 1000, 10000} {
+		b.Run(fmt.Sprintf("%d-mounts", n), func(b *testing.B) {
+			mfs, _ := buildManyMounts(b, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				prefix := fmt.Sprintf("/bench/%d/%d", i%100, i)
+				if err := mfs.Mount(prefix, fstest.MapFS{}); err != nil {
+					b.Fatalf(`mfs.Mount(%q) should succeed: %s`, prefix, err)
+				}
+				if err := mfs.Unmount(prefix); err != nil {
+					b.Fatalf(`mfs.Unmount(%q) should succeed: %s`, prefix, err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkOpen(b *testing.B) {
+	for _, n := range []int{10, 100, 1000, 10000} {
+		b.Run(fmt.Sprintf("%d-mounts", n), func(b *testing.B) {
+			mfs, prefixes := buildManyMounts(b, n)
+			target := prefixes[len(prefixes)-1] + "/file.txt"
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := mfs.Open(target); err != nil {
+					b.Fatalf(`mfs.Open(%q) should succeed: %s`, target, err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReadDir(b *testing.B) {
+	for _, n := range []int{10, 100, 1000, 10000} {
+		b.Run(fmt.Sprintf("%d-mounts", n), func(b *testing.B) {
+			mfs, _ := buildManyMounts(b, n)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := mfs.ReadDir("/mnt"); err != nil {
+					b.Fatalf(`mfs.ReadDir("/mnt") should succeed: %s`, err)
+				}
+			}
+		})
+	}
+}
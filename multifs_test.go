@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/lestrrat-go/multifs"
 	"github.com/stretchr/testify/require"
@@ -112,3 +113,78 @@ func TestMultiFS(t *testing.T) {
 	require.NoError(t, mfs.Unmount("/quux"), `fs.Unmount(/quux) should succeed`)
 	require.Error(t, mfs.Unmount("/corge"), `fs.Unmount(/corge) a second time should fail`)
 }
+
+func TestBind(t *testing.T) {
+	base := fstest.MapFS{
+		"shared.txt":    &fstest.MapFile{Data: []byte("base")},
+		"base-only.txt": &fstest.MapFile{Data: []byte("base-only")},
+	}
+	overlay := fstest.MapFS{
+		"shared.txt":       &fstest.MapFile{Data: []byte("overlay")},
+		"overlay-only.txt": &fstest.MapFile{Data: []byte("overlay-only")},
+	}
+
+	var mfs multifs.FS
+	require.NoError(t, mfs.Bind("/", base, ".", multifs.BindAfter), `mfs.Bind(base) should succeed`)
+	require.NoError(t, mfs.Bind("/", overlay, ".", multifs.BindBefore), `mfs.Bind(overlay) should succeed`)
+
+	t.Run("overlay wins on conflict", func(t *testing.T) {
+		data, err := fs.ReadFile(&mfs, "/shared.txt")
+		require.NoError(t, err, `fs.ReadFile(/shared.txt) should succeed`)
+		require.Equal(t, "overlay", string(data), `overlay binding should win`)
+	})
+
+	t.Run("base is reachable when not shadowed", func(t *testing.T) {
+		data, err := fs.ReadFile(&mfs, "/base-only.txt")
+		require.NoError(t, err, `fs.ReadFile(/base-only.txt) should succeed`)
+		require.Equal(t, "base-only", string(data))
+	})
+
+	t.Run("overlay-only file is reachable", func(t *testing.T) {
+		data, err := fs.ReadFile(&mfs, "/overlay-only.txt")
+		require.NoError(t, err, `fs.ReadFile(/overlay-only.txt) should succeed`)
+		require.Equal(t, "overlay-only", string(data))
+	})
+
+	t.Run("ReadDir merges and de-duplicates entries", func(t *testing.T) {
+		entries, err := mfs.ReadDir("/")
+		require.NoError(t, err, `mfs.ReadDir(/) should succeed`)
+
+		names := make(map[string]struct{})
+		for _, entry := range entries {
+			names[entry.Name()] = struct{}{}
+		}
+		require.Len(t, names, 3, `merged entries should be de-duplicated`)
+		require.Contains(t, names, "shared.txt")
+		require.Contains(t, names, "base-only.txt")
+		require.Contains(t, names, "overlay-only.txt")
+	})
+}
+
+func TestMountSub(t *testing.T) {
+	src := fstest.MapFS{
+		"internal/assets/foo.js": &fstest.MapFile{Data: []byte("foo")},
+		"internal/assets/bar.js": &fstest.MapFile{Data: []byte("bar")},
+		"internal/other.txt":     &fstest.MapFile{Data: []byte("other")},
+	}
+
+	var mfs multifs.FS
+	require.NoError(t, mfs.MountSub("/static", src, "internal/assets"), `mfs.MountSub should succeed`)
+
+	t.Run("file under subdir is reachable", func(t *testing.T) {
+		data, err := fs.ReadFile(&mfs, "/static/foo.js")
+		require.NoError(t, err, `fs.ReadFile(/static/foo.js) should succeed`)
+		require.Equal(t, "foo", string(data))
+	})
+
+	t.Run("file outside subdir is not reachable", func(t *testing.T) {
+		_, err := fs.ReadFile(&mfs, "/static/other.txt")
+		require.Error(t, err, `files outside the mounted subdir should not be reachable`)
+	})
+
+	t.Run("ReadDir lists only the subdir's entries", func(t *testing.T) {
+		entries, err := mfs.ReadDir("/static")
+		require.NoError(t, err, `mfs.ReadDir(/static) should succeed`)
+		require.Len(t, entries, 2)
+	})
+}
@@ -0,0 +1,351 @@
+package multifs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// whiteoutPrefix marks a directory entry in the upper layer as
+// recording the deletion of an entry with the same base name in the
+// lower layer, e.g. removing "/foo/bar" creates "/foo/.wh.bar".
+const whiteoutPrefix = ".wh."
+
+// overlayFS implements a copy-on-write union of a read-only lower
+// filesystem and a writable upper filesystem: reads fall through to
+// lower until a write materializes the file in upper, and deletions are
+// recorded as whiteout marker files in upper that mask the
+// corresponding lower entries.
+type overlayFS struct {
+	lower fs.FS
+	upper WritableFS
+}
+
+var (
+	_ fs.FS      = (*overlayFS)(nil)
+	_ WritableFS = (*overlayFS)(nil)
+)
+
+// NewOverlayFS returns an fs.FS that presents upper layered over lower,
+// copy-on-write: files are read from upper if present there, otherwise
+// from lower, and writes/removals only ever touch upper. The returned
+// value also implements WritableFS; callers that need to write should
+// type-assert for it.
+func NewOverlayFS(lower fs.FS, upper WritableFS) fs.FS {
+	return &overlayFS{lower: lower, upper: upper}
+}
+
+func whiteoutPath(name string) string {
+	dir, base := path.Split(name)
+	return path.Join(dir, whiteoutPrefix+base)
+}
+
+// isWhitedOut reports whether name, or any of its ancestor directories,
+// has been masked by a whiteout marker in upper.
+func (o *overlayFS) isWhitedOut(name string) bool {
+	for p := path.Clean(name); p != "." && p != "/"; {
+		if _, err := fs.Stat(o.upper, whiteoutPath(p)); err == nil {
+			return true
+		}
+		parent := path.Dir(p)
+		if parent == p {
+			break
+		}
+		p = parent
+	}
+	return false
+}
+
+func (o *overlayFS) clearWhiteout(name string) error {
+	err := o.upper.Remove(whiteoutPath(name))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (o *overlayFS) writeWhiteout(name string) error {
+	wh := whiteoutPath(name)
+	if dir := path.Dir(wh); dir != "." {
+		if err := o.upper.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := o.upper.OpenFile(wh, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	if o.isWhitedOut(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	f, err := o.upper.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.lower.Open(name)
+}
+
+func (o *overlayFS) Stat(name string) (fs.FileInfo, error) {
+	if o.isWhitedOut(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	fi, err := fs.Stat(o.upper, name)
+	if err == nil {
+		return fi, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return fs.Stat(o.lower, name)
+}
+
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if o.isWhitedOut(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	upperEntries, upperErr := fs.ReadDir(o.upper, name)
+	lowerEntries, lowerErr := fs.ReadDir(o.lower, name)
+	if upperErr != nil && lowerErr != nil {
+		return nil, lowerErr
+	}
+
+	whiteouts := make(map[string]struct{})
+	seen := make(map[string]struct{})
+	merged := make([]fs.DirEntry, 0, len(upperEntries)+len(lowerEntries))
+	for _, entry := range upperEntries {
+		if strings.HasPrefix(entry.Name(), whiteoutPrefix) {
+			whiteouts[strings.TrimPrefix(entry.Name(), whiteoutPrefix)] = struct{}{}
+			continue
+		}
+		seen[entry.Name()] = struct{}{}
+		merged = append(merged, entry)
+	}
+	for _, entry := range lowerEntries {
+		if _, ok := seen[entry.Name()]; ok {
+			continue
+		}
+		if _, ok := whiteouts[entry.Name()]; ok {
+			continue
+		}
+		merged = append(merged, entry)
+	}
+	return merged, nil
+}
+
+// copyUp materializes a copy of the lower layer's version of name into
+// upper, so that a subsequent write only ever touches upper. It is a
+// no-op (without error) if name does not exist in lower.
+func (o *overlayFS) copyUp(name string, perm fs.FileMode) error {
+	data, err := fs.ReadFile(o.lower, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if dir := path.Dir(name); dir != "." {
+		if err := o.upper.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	f, err := o.upper.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (o *overlayFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	name = path.Clean(name)
+	whitedOut := o.isWhitedOut(name)
+	if whitedOut {
+		if flag&os.O_CREATE == 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		if err := o.clearWhiteout(name); err != nil {
+			return nil, err
+		}
+	}
+
+	wantsWrite := flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+	if !wantsWrite {
+		if f, err := o.upper.OpenFile(name, os.O_RDONLY, 0); err == nil {
+			return f, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		f, err := o.lower.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		return readOnlyWritableFile{f}, nil
+	}
+
+	if !whitedOut {
+		if _, err := fs.Stat(o.upper, name); errors.Is(err, fs.ErrNotExist) {
+			if err := o.copyUp(name, perm); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// copyUp does nothing when name doesn't exist in lower (e.g. it's a
+	// brand-new file), so name's parent may still
+	// only exist in lower, so upper needs it created before the file can
+	// be opened there.
+	if flag&os.O_CREATE != 0 {
+		if dir := path.Dir(name); dir != "." {
+			if err := o.upper.MkdirAll(dir, 0o755); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return o.upper.OpenFile(name, flag, perm)
+}
+
+func (o *overlayFS) Mkdir(name string, perm fs.FileMode) error {
+	name = path.Clean(name)
+	if o.isWhitedOut(name) {
+		if err := o.clearWhiteout(name); err != nil {
+			return err
+		}
+	}
+	return o.upper.Mkdir(name, perm)
+}
+
+func (o *overlayFS) MkdirAll(name string, perm fs.FileMode) error {
+	name = path.Clean(name)
+	if o.isWhitedOut(name) {
+		if err := o.clearWhiteout(name); err != nil {
+			return err
+		}
+	}
+	return o.upper.MkdirAll(name, perm)
+}
+
+func (o *overlayFS) Remove(name string) error {
+	name = path.Clean(name)
+
+	_, upperErr := fs.Stat(o.upper, name)
+	_, lowerErr := fs.Stat(o.lower, name)
+	existsInLower := lowerErr == nil && !o.isWhitedOut(name)
+	if upperErr != nil && !existsInLower {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if upperErr == nil {
+		if err := o.upper.Remove(name); err != nil {
+			return err
+		}
+	}
+	if existsInLower {
+		return o.writeWhiteout(name)
+	}
+	return nil
+}
+
+func (o *overlayFS) RemoveAll(name string) error {
+	name = path.Clean(name)
+
+	_, upperErr := fs.Stat(o.upper, name)
+	_, lowerErr := fs.Stat(o.lower, name)
+	existsInLower := lowerErr == nil && !o.isWhitedOut(name)
+	if upperErr != nil && !existsInLower {
+		return nil
+	}
+
+	if upperErr == nil {
+		if err := o.upper.RemoveAll(name); err != nil {
+			return err
+		}
+	}
+	if existsInLower {
+		return o.writeWhiteout(name)
+	}
+	return nil
+}
+
+func (o *overlayFS) Rename(oldname, newname string) error {
+	oldname = path.Clean(oldname)
+	newname = path.Clean(newname)
+
+	whitedOut := o.isWhitedOut(oldname)
+	if whitedOut {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+
+	if _, err := fs.Stat(o.upper, oldname); errors.Is(err, fs.ErrNotExist) {
+		fi, statErr := fs.Stat(o.lower, oldname)
+		if statErr != nil {
+			return statErr
+		}
+		if err := o.copyUp(oldname, fi.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	if err := o.upper.Rename(oldname, newname); err != nil {
+		return err
+	}
+
+	if _, err := fs.Stat(o.lower, oldname); err == nil {
+		return o.writeWhiteout(oldname)
+	}
+	return nil
+}
+
+func (o *overlayFS) Chtimes(name string, atime, mtime time.Time) error {
+	name = path.Clean(name)
+	if o.isWhitedOut(name) {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if _, err := fs.Stat(o.upper, name); errors.Is(err, fs.ErrNotExist) {
+		fi, statErr := fs.Stat(o.lower, name)
+		if statErr != nil {
+			return statErr
+		}
+		if err := o.copyUp(name, fi.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return o.upper.Chtimes(name, atime, mtime)
+}
+
+// readOnlyWritableFile adapts a plain fs.File (typically from the lower,
+// read-only layer) to the WritableFile interface; every write operation
+// fails, since the underlying file was never opened for writing.
+type readOnlyWritableFile struct {
+	fs.File
+}
+
+func (readOnlyWritableFile) Write([]byte) (int, error) {
+	return 0, fs.ErrPermission
+}
+
+func (readOnlyWritableFile) WriteAt([]byte, int64) (int, error) {
+	return 0, fs.ErrPermission
+}
+
+func (readOnlyWritableFile) Seek(int64, int) (int64, error) {
+	return 0, fs.ErrPermission
+}
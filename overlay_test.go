@@ -0,0 +1,174 @@
+package multifs_test
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lestrrat-go/multifs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayFS(t *testing.T) {
+	lower := fstest.MapFS{
+		"a.txt":            &fstest.MapFile{Data: []byte("lower-a")},
+		"b.txt":            &fstest.MapFile{Data: []byte("lower-b")},
+		"dir/existing.txt": &fstest.MapFile{Data: []byte("lower-dir")},
+	}
+
+	newOverlay := func() (fs.FS, multifs.WritableFS) {
+		ofs := multifs.NewOverlayFS(lower, multifs.NewMemFS())
+		return ofs, ofs.(multifs.WritableFS)
+	}
+
+	t.Run("reads fall through to lower", func(t *testing.T) {
+		ofs, _ := newOverlay()
+		data, err := fs.ReadFile(ofs, "a.txt")
+		require.NoError(t, err, `fs.ReadFile(a.txt) should succeed`)
+		require.Equal(t, "lower-a", string(data))
+	})
+
+	t.Run("write materializes the file in upper", func(t *testing.T) {
+		ofs, wfs := newOverlay()
+
+		f, err := wfs.OpenFile("a.txt", os.O_WRONLY|os.O_TRUNC, 0o644)
+		require.NoError(t, err, `wfs.OpenFile(a.txt) should succeed`)
+		_, err = f.Write([]byte("overlay-a"))
+		require.NoError(t, err, `f.Write should succeed`)
+		require.NoError(t, f.Close())
+
+		data, err := fs.ReadFile(ofs, "a.txt")
+		require.NoError(t, err, `fs.ReadFile(a.txt) should succeed`)
+		require.Equal(t, "overlay-a", string(data), `write should shadow the lower copy`)
+
+		data, err = fs.ReadFile(lower, "a.txt")
+		require.NoError(t, err)
+		require.Equal(t, "lower-a", string(data), `lower should be untouched`)
+	})
+
+	t.Run("remove masks the lower file with a whiteout", func(t *testing.T) {
+		ofs, wfs := newOverlay()
+
+		require.NoError(t, wfs.Remove("b.txt"), `wfs.Remove(b.txt) should succeed`)
+
+		_, err := fs.Stat(ofs, "b.txt")
+		require.ErrorIs(t, err, fs.ErrNotExist, `b.txt should appear deleted`)
+
+		entries, err := fs.ReadDir(ofs, ".")
+		require.NoError(t, err, `fs.ReadDir(.) should succeed`)
+		for _, entry := range entries {
+			require.NotEqual(t, "b.txt", entry.Name(), `b.txt should not be listed`)
+		}
+	})
+
+	t.Run("new file can be created directly in upper", func(t *testing.T) {
+		ofs, wfs := newOverlay()
+
+		f, err := wfs.OpenFile("c.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+		require.NoError(t, err, `wfs.OpenFile(c.txt, O_CREATE) should succeed`)
+		_, err = f.Write([]byte("new-c"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		data, err := fs.ReadFile(ofs, "c.txt")
+		require.NoError(t, err, `fs.ReadFile(c.txt) should succeed`)
+		require.Equal(t, "new-c", string(data))
+	})
+
+	t.Run("new file can be created under a directory that only exists in lower", func(t *testing.T) {
+		ofs, wfs := newOverlay()
+
+		f, err := wfs.OpenFile("dir/new.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+		require.NoError(t, err, `wfs.OpenFile(dir/new.txt, O_CREATE) should succeed`)
+		_, err = f.Write([]byte("new-in-dir"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		data, err := fs.ReadFile(ofs, "dir/new.txt")
+		require.NoError(t, err, `fs.ReadFile(dir/new.txt) should succeed`)
+		require.Equal(t, "new-in-dir", string(data))
+
+		data, err = fs.ReadFile(ofs, "dir/existing.txt")
+		require.NoError(t, err, `the pre-existing lower-only file should still be reachable`)
+		require.Equal(t, "lower-dir", string(data))
+	})
+}
+
+func TestMemFS(t *testing.T) {
+	mfs := multifs.NewMemFS()
+
+	require.NoError(t, mfs.MkdirAll("dir/sub", 0o755), `mfs.MkdirAll should succeed`)
+
+	f, err := mfs.OpenFile("dir/sub/file.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err, `mfs.OpenFile should succeed`)
+	_, err = f.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	data, err := fs.ReadFile(mfs, "dir/sub/file.txt")
+	require.NoError(t, err, `fs.ReadFile should succeed`)
+	require.Equal(t, "hello", string(data))
+
+	require.NoError(t, mfs.Rename("dir/sub/file.txt", "dir/sub/renamed.txt"), `mfs.Rename should succeed`)
+	_, err = fs.Stat(mfs, "dir/sub/file.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	data, err = fs.ReadFile(mfs, "dir/sub/renamed.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+}
+
+func TestMemFSRenameOntoNonEmptyDir(t *testing.T) {
+	mfs := multifs.NewMemFS()
+
+	require.NoError(t, mfs.MkdirAll("a", 0o755), `mfs.MkdirAll(a) should succeed`)
+	f, err := mfs.OpenFile("a/x.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	require.NoError(t, mfs.MkdirAll("b", 0o755), `mfs.MkdirAll(b) should succeed`)
+	f, err = mfs.OpenFile("b/y.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	err = mfs.Rename("a", "b")
+	require.ErrorIs(t, err, fs.ErrInvalid, `renaming onto a non-empty directory should fail`)
+
+	entries, err := mfs.ReadDir("b")
+	require.NoError(t, err)
+	require.Len(t, entries, 1, `the pre-existing destination should be left untouched`)
+	require.Equal(t, "y.txt", entries[0].Name())
+}
+
+func TestMemFSRenameMissingParent(t *testing.T) {
+	mfs := multifs.NewMemFS()
+
+	f, err := mfs.OpenFile("a.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	err = mfs.Rename("a.txt", "newdir/a.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist, `renaming into a directory that doesn't exist should fail`)
+
+	entries, err := mfs.ReadDir(".")
+	require.NoError(t, err)
+	require.Len(t, entries, 1, `a.txt should not have been orphaned`)
+	require.Equal(t, "a.txt", entries[0].Name())
+}
+
+func TestMemFSRenameSelfIsNoop(t *testing.T) {
+	mfs := multifs.NewMemFS()
+
+	f, err := mfs.OpenFile("a.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	require.NoError(t, mfs.Rename("a.txt", "a.txt"), `renaming a path onto itself should be a no-op`)
+
+	data, err := fs.ReadFile(mfs, "a.txt")
+	require.NoError(t, err, `a.txt should still exist`)
+	require.Equal(t, "hello", string(data))
+}
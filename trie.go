@@ -0,0 +1,100 @@
+package multifs
+
+import "strings"
+
+// trieNode is one segment of a mount point path. Mounting "/a/b/c"
+// walks (creating as needed) the chain root -> "a" -> "b" -> "c" and
+// stores the binding on the "c" node; intermediate nodes may or may not
+// carry bindings of their own.
+type trieNode struct {
+	children map[string]*trieNode
+	bindings []binding
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{children: make(map[string]*trieNode)}
+}
+
+// pathSegments splits an absolute (or root/empty) path into its
+// "/"-separated components, e.g. "/a/b" -> ["a", "b"], "/" -> nil.
+func pathSegments(p string) []string {
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func joinSegments(segments []string) string {
+	if len(segments) == 0 {
+		return "/"
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// nodeForWrite returns the node for prefix, creating any missing
+// segments along the way. Callers must hold mfs.mu for writing.
+func (mfs *FS) nodeForWrite(prefix string) *trieNode {
+	cur := mfs.root
+	for _, seg := range pathSegments(prefix) {
+		child, ok := cur.children[seg]
+		if !ok {
+			child = newTrieNode()
+			cur.children[seg] = child
+		}
+		cur = child
+	}
+	return cur
+}
+
+// nodeAt returns the node at path, or nil if no mount point has ever
+// created that node (i.e. path isn't a mount point, nor an ancestor
+// directory of one).
+func (mfs *FS) nodeAt(p string) *trieNode {
+	cur := mfs.root
+	for _, seg := range pathSegments(p) {
+		child, ok := cur.children[seg]
+		if !ok {
+			return nil
+		}
+		cur = child
+	}
+	return cur
+}
+
+// resolve walks name's path segments through the trie and returns the
+// bindings and prefix of the deepest mount point that applies to name,
+// in O(depth) rather than a linear scan of every mount.
+//
+// When allowExact is true, a mount point exactly at name wins (used by
+// Stat/ReadDir, which can address a mount point directly); otherwise
+// only a strict ancestor of name counts (used by Open, matching the
+// "/foo/bar.txt" style of access). A mount at "/" always applies,
+// since it is the root of the namespace either way.
+func (mfs *FS) resolve(name string, allowExact bool) (prefix string, bindings []binding, ok bool) {
+	if mfs.root == nil {
+		return "", nil, false
+	}
+
+	if mfs.root.bindings != nil {
+		prefix, bindings, ok = "/", mfs.root.bindings, true
+	}
+
+	segments := pathSegments(name)
+	cur := mfs.root
+	for i, seg := range segments {
+		child, exists := cur.children[seg]
+		if !exists {
+			break
+		}
+		cur = child
+
+		if i == len(segments)-1 && !allowExact {
+			break
+		}
+		if cur.bindings != nil {
+			prefix, bindings, ok = joinSegments(segments[:i+1]), cur.bindings, true
+		}
+	}
+	return prefix, bindings, ok
+}
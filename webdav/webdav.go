@@ -0,0 +1,191 @@
+// Package webdav adapts a *multifs.FS to golang.org/x/net/webdav, so a
+// composed namespace of heterogeneous mounts (local directories, zip
+// files, S3 buckets, ...) can be served over HTTP via
+// PROPFIND/GET/PUT/MKCOL/MOVE/COPY.
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/lestrrat-go/multifs"
+)
+
+// FileSystem adapts a *multifs.FS to webdav.FileSystem. Reads (GET,
+// PROPFIND) are served directly from the composed namespace, including
+// the pseudo-directories multifs.FS synthesizes for mount points that
+// don't have anything mounted directly at their parent. Writes (PUT,
+// MKCOL, MOVE, DELETE) require that whatever is mounted at the target
+// path also implements multifs.WritableFS; otherwise they fail.
+type FileSystem struct {
+	mfs *multifs.FS
+}
+
+var _ webdav.FileSystem = (*FileSystem)(nil)
+
+// New adapts mfs to webdav.FileSystem.
+func New(mfs *multifs.FS) *FileSystem {
+	return &FileSystem{mfs: mfs}
+}
+
+// NewHandler returns a *webdav.Handler serving mfs under prefix, using
+// locks to satisfy the WebDAV locking protocol. Pass webdav.NewMemLS()
+// for a simple in-memory LockSystem, or a custom one to share locks
+// across handlers or persist them outside memory.
+func NewHandler(prefix string, mfs *multifs.FS, locks webdav.LockSystem) *webdav.Handler {
+	return &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: New(mfs),
+		LockSystem: locks,
+	}
+}
+
+func (fsys *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	wfs, rel, err := fsys.mfs.ResolveWritable(name)
+	if err != nil {
+		return err
+	}
+	return wfs.Mkdir(rel, perm)
+}
+
+const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_CREATE | os.O_TRUNC | os.O_APPEND
+
+func (fsys *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&writeFlags != 0 {
+		wfs, rel, err := fsys.mfs.ResolveWritable(name)
+		if err != nil {
+			return nil, err
+		}
+		f, err := wfs.OpenFile(rel, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return &writableFile{WritableFile: f}, nil
+	}
+
+	fi, err := fsys.mfs.StatCtx(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		entries, err := fsys.mfs.ReadDirCtx(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		infos := make([]fs.FileInfo, 0, len(entries))
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+		return &dirFile{info: fi, entries: infos}, nil
+	}
+
+	f, err := fsys.mfs.OpenCtx(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyFile{File: f}, nil
+}
+
+func (fsys *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	wfs, rel, err := fsys.mfs.ResolveWritable(name)
+	if err != nil {
+		return err
+	}
+	return wfs.RemoveAll(rel)
+}
+
+func (fsys *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldWfs, oldRel, err := fsys.mfs.ResolveWritable(oldName)
+	if err != nil {
+		return err
+	}
+	newWfs, newRel, err := fsys.mfs.ResolveWritable(newName)
+	if err != nil {
+		return err
+	}
+	if oldWfs != newWfs {
+		return fmt.Errorf(`cannot rename %q to %q: rename across mounted filesystems is not supported`, oldName, newName)
+	}
+	return oldWfs.Rename(oldRel, newRel)
+}
+
+func (fsys *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return fsys.mfs.StatCtx(ctx, name)
+}
+
+// readOnlyFile adapts the fs.File returned by multifs.FS.OpenCtx to
+// webdav.File: every write fails, since the underlying mount was only
+// opened for reading.
+type readOnlyFile struct {
+	fs.File
+}
+
+func (f *readOnlyFile) Write([]byte) (int, error) {
+	return 0, fs.ErrPermission
+}
+
+func (f *readOnlyFile) Seek(offset int64, whence int) (int64, error) {
+	if s, ok := f.File.(io.Seeker); ok {
+		return s.Seek(offset, whence)
+	}
+	return 0, fs.ErrInvalid
+}
+
+func (f *readOnlyFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, fs.ErrInvalid
+}
+
+// writableFile adapts a multifs.WritableFile to webdav.File.
+type writableFile struct {
+	multifs.WritableFile
+}
+
+func (f *writableFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, fs.ErrInvalid
+}
+
+// dirFile implements webdav.File for a directory: its entries are
+// gathered up front from multifs.FS.ReadDirCtx, including any
+// pseudo-directory entries synthesized for mount points that have
+// nothing mounted directly at their parent.
+type dirFile struct {
+	info    fs.FileInfo
+	entries []fs.FileInfo
+	offset  int
+}
+
+func (d *dirFile) Close() error              { return nil }
+func (d *dirFile) Read([]byte) (int, error)  { return 0, fs.ErrInvalid }
+func (d *dirFile) Write([]byte) (int, error) { return 0, fs.ErrPermission }
+func (d *dirFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, fs.ErrInvalid
+}
+func (d *dirFile) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *dirFile) Readdir(count int) ([]fs.FileInfo, error) {
+	if count <= 0 {
+		entries := d.entries[d.offset:]
+		d.offset = len(d.entries)
+		return entries, nil
+	}
+
+	if d.offset >= len(d.entries) {
+		return nil, io.EOF
+	}
+	end := d.offset + count
+	if end > len(d.entries) {
+		end = len(d.entries)
+	}
+	entries := d.entries[d.offset:end]
+	d.offset = end
+	return entries, nil
+}
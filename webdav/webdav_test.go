@@ -0,0 +1,85 @@
+package webdav_test
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lestrrat-go/multifs"
+	"github.com/lestrrat-go/multifs/webdav"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFS(t *testing.T) *multifs.FS {
+	t.Helper()
+
+	mfs := multifs.New()
+	require.NoError(t, mfs.Mount("/static", fstest.MapFS{
+		"hello.txt": &fstest.MapFile{Data: []byte("hello")},
+	}), `mfs.Mount(/static) should succeed`)
+	require.NoError(t, mfs.MountWritable("/mem", multifs.NewMemFS()), `mfs.MountWritable(/mem) should succeed`)
+
+	return mfs
+}
+
+func TestFileSystemOpenFileGet(t *testing.T) {
+	fsys := webdav.New(newTestFS(t))
+
+	f, err := fsys.OpenFile(context.Background(), "/static/hello.txt", os.O_RDONLY, 0)
+	require.NoError(t, err, `fsys.OpenFile should succeed`)
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+}
+
+func TestFileSystemOpenFileReadDirPseudoMounts(t *testing.T) {
+	fsys := webdav.New(newTestFS(t))
+
+	f, err := fsys.OpenFile(context.Background(), "/", os.O_RDONLY, 0)
+	require.NoError(t, err, `fsys.OpenFile(/) should succeed`)
+	defer f.Close()
+
+	entries, err := f.Readdir(-1)
+	require.NoError(t, err, `f.Readdir should succeed`)
+
+	names := make(map[string]struct{}, len(entries))
+	for _, fi := range entries {
+		names[fi.Name()] = struct{}{}
+	}
+	require.Contains(t, names, "static", `pseudo-directory for /static should be listed`)
+	require.Contains(t, names, "mem", `pseudo-directory for /mem should be listed`)
+}
+
+func TestFileSystemOpenFileWritesThroughWritableMount(t *testing.T) {
+	mfs := newTestFS(t)
+	fsys := webdav.New(mfs)
+
+	f, err := fsys.OpenFile(context.Background(), "/mem/new.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err, `fsys.OpenFile should succeed`)
+	_, err = f.Write([]byte("written"))
+	require.NoError(t, err, `f.Write should succeed`)
+	require.NoError(t, f.Close())
+
+	data, err := fs.ReadFile(mfs, "/mem/new.txt")
+	require.NoError(t, err, `fs.ReadFile should see the write`)
+	require.Equal(t, "written", string(data))
+}
+
+func TestFileSystemRenameAcrossMountsFails(t *testing.T) {
+	mfs := newTestFS(t)
+	fsys := webdav.New(mfs)
+
+	f, err := fsys.OpenFile(context.Background(), "/mem/a.txt", os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err, `fsys.OpenFile should succeed`)
+	require.NoError(t, f.Close())
+
+	require.NoError(t, mfs.MountWritable("/mem2", multifs.NewMemFS()), `mfs.MountWritable(/mem2) should succeed`)
+
+	err = fsys.Rename(context.Background(), "/mem/a.txt", "/mem2/a.txt")
+	require.Error(t, err, `rename across mounted filesystems should be rejected`)
+}
@@ -0,0 +1,74 @@
+package multifs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// WritableFile is returned by WritableFS.OpenFile. In addition to the
+// usual fs.File methods, it supports writing, modeled on afero.File.
+type WritableFile interface {
+	fs.File
+	io.Writer
+	io.WriterAt
+	io.Seeker
+}
+
+// WritableFS is implemented by filesystems that support write
+// operations, modeled on afero.Fs. Mounting a WritableFS works exactly
+// like mounting a plain fs.FS (via Mount/Bind); MountWritable is
+// provided purely so that the writable capability is visible at the
+// call site. Code that needs to perform writes against a mounted
+// filesystem can type-assert the fs.FS it got back (e.g. from
+// NewOverlayFS) to WritableFS.
+type WritableFS interface {
+	fs.FS
+
+	OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error)
+	Mkdir(name string, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldname, newname string) error
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// MountWritable associates prefix with a WritableFS. It behaves exactly
+// like Mount; it exists so that callers mounting a writable filesystem
+// don't have to rely on WritableFS's implicit satisfaction of fs.FS.
+func (mfs *FS) MountWritable(prefix string, other WritableFS) error {
+	return mfs.Mount(prefix, other)
+}
+
+// ResolveWritable finds the first WritableFS binding covering name and
+// returns it along with name translated into that filesystem's own
+// namespace. It is meant for adapters (such as multifs/webdav) that
+// need to perform a write directly against the concrete filesystem
+// backing a path, rather than through FS's own read-only fs.FS surface.
+func (mfs *FS) ResolveWritable(name string) (WritableFS, string, error) {
+	name = path.Clean(name)
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+
+	prefix, bindings, ok := mfs.resolve(name, false)
+	if !ok {
+		return nil, "", fmt.Errorf(`file %q was not found`, name)
+	}
+
+	for _, b := range bindings {
+		wfs, ok := b.fs.(WritableFS)
+		if !ok {
+			continue
+		}
+		return wfs, b.translate(prefix, name), nil
+	}
+	return nil, "", fmt.Errorf(`no writable filesystem mounted at %q`, prefix)
+}